@@ -0,0 +1,51 @@
+// Command smoketest is a standalone sanity check for the QR pairing flow:
+// it connects to /qr/stream and prints each QR code and connection event as
+// it arrives, replacing the old qrcode.txt/qr_code.png polling flow.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type streamEvent struct {
+	Type string `json:"type"`
+	Code string `json:"code,omitempty"`
+}
+
+func main() {
+	resp, err := http.Get("http://localhost:8080/qr/stream")
+	if err != nil {
+		log.Fatalf("Failed to connect to QR stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		data := strings.TrimPrefix(line, "data: ")
+
+		var evt streamEvent
+		if err := json.Unmarshal([]byte(data), &evt); err != nil {
+			log.Printf("Failed to unmarshal event: %v", err)
+			continue
+		}
+
+		if evt.Code != "" {
+			fmt.Printf("QR code: %s\n", evt.Code)
+		} else {
+			fmt.Printf("Event: %s\n", evt.Type)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("QR stream closed: %v", err)
+	}
+}
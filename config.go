@@ -0,0 +1,102 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the tunables for outbound message handling: how long a
+// single send is allowed to take, how aggressively failed sends are
+// retried, and how many workers a bulk send fans out across.
+type Config struct {
+	MessageHandlingDeadline time.Duration `yaml:"message_handling_deadline"`
+	RetryMaxAttempts        int           `yaml:"retry_max_attempts"`
+	RetryBaseBackoff        time.Duration `yaml:"retry_base_backoff"`
+	BulkWorkerCount         int           `yaml:"bulk_worker_count"`
+
+	// Sinks lists which MessageSink backends a session fans its message
+	// events out to: any combination of "csv", "jsonl", "sqlite", "webhook".
+	Sinks         []string `yaml:"sinks"`
+	WebhookURL    string   `yaml:"webhook_url"`
+	SinkQueueSize int      `yaml:"sink_queue_size"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		MessageHandlingDeadline: 60 * time.Second,
+		RetryMaxAttempts:        3,
+		RetryBaseBackoff:        time.Second,
+		BulkWorkerCount:         5,
+		Sinks:                   []string{"csv"},
+		SinkQueueSize:           256,
+	}
+}
+
+// LoadConfig builds the active Config, starting from defaults, overlaying
+// config.yaml if one is present, then overlaying any of the env vars
+// below, which always win.
+//
+//	MESSAGE_HANDLING_DEADLINE (duration, e.g. "45s")
+//	RETRY_MAX_ATTEMPTS        (int)
+//	RETRY_BASE_BACKOFF        (duration, e.g. "500ms")
+//	BULK_WORKER_COUNT         (int)
+//	SINKS                     (comma-separated, e.g. "csv,jsonl,webhook")
+//	WEBHOOK_URL               (string)
+//	SINK_QUEUE_SIZE           (int)
+func LoadConfig() Config {
+	cfg := defaultConfig()
+
+	if data, err := os.ReadFile("config.yaml"); err == nil {
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			log.Printf("Failed to parse config.yaml: %v", err)
+		}
+	}
+
+	if v := os.Getenv("MESSAGE_HANDLING_DEADLINE"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.MessageHandlingDeadline = d
+		}
+	}
+	if v := os.Getenv("RETRY_MAX_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.RetryMaxAttempts = n
+		}
+	}
+	if v := os.Getenv("RETRY_BASE_BACKOFF"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.RetryBaseBackoff = d
+		}
+	}
+	if v := os.Getenv("BULK_WORKER_COUNT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.BulkWorkerCount = n
+		}
+	}
+	if v := os.Getenv("SINKS"); v != "" {
+		cfg.Sinks = strings.Split(v, ",")
+	}
+	if v := os.Getenv("WEBHOOK_URL"); v != "" {
+		cfg.WebhookURL = v
+	}
+	if v := os.Getenv("SINK_QUEUE_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.SinkQueueSize = n
+		}
+	}
+
+	// A retry loop of "try once, no retries" is a reasonable config value,
+	// but it's expressed as a single attempt, not zero: the retry loop in
+	// sendMessage never runs its body at zero and would otherwise fall
+	// through to a false "delivered" outcome without ever calling
+	// client.SendMessage.
+	if cfg.RetryMaxAttempts < 1 {
+		cfg.RetryMaxAttempts = 1
+	}
+
+	return cfg
+}
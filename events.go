@@ -0,0 +1,57 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// StreamEvent is a single frame pushed to a session's /qr/stream
+// subscribers: either a QR rotation event (code/timeout/success/err) or a
+// connection lifecycle event (connected/disconnected/logged_out/
+// offline_sync_completed).
+type StreamEvent struct {
+	Type      string    `json:"type"`
+	Code      string    `json:"code,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// eventBroker fans a session's QR and connection events out to every
+// currently subscribed SSE client.
+type eventBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan StreamEvent]struct{}
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{subscribers: make(map[chan StreamEvent]struct{})}
+}
+
+// Subscribe registers a new listener and returns its event channel plus an
+// unsubscribe function the caller must invoke once it stops reading.
+func (b *eventBroker) Subscribe() (<-chan StreamEvent, func()) {
+	ch := make(chan StreamEvent, 16)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		delete(b.subscribers, ch)
+		b.mu.Unlock()
+		close(ch)
+	}
+	return ch, unsubscribe
+}
+
+// Publish sends evt to every current subscriber without blocking; a slow
+// subscriber drops the event rather than stalling the whatsmeow event loop.
+func (b *eventBroker) Publish(evt StreamEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- evt:
+		default:
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// parseParticipantJIDs resolves each entry the same way parseTargetJID
+// does for /send: a bare phone number is assumed to be an individual JID,
+// while anything already containing "@" (e.g. a JID echoed back from
+// GET /groups) is parsed as-is instead of having a server suffix appended
+// a second time.
+func parseParticipantJIDs(numbers []string) ([]types.JID, error) {
+	jids := make([]types.JID, 0, len(numbers))
+	for _, n := range numbers {
+		jid, err := parseTargetJID(n)
+		if err != nil {
+			return nil, fmt.Errorf("invalid participant JID %q: %w", n, err)
+		}
+		jids = append(jids, jid)
+	}
+	return jids, nil
+}
+
+// Handler to create a new WhatsApp group
+func createGroupHandler(c *gin.Context) {
+	var request struct {
+		SessionID    string   `json:"session_id"`
+		Name         string   `json:"name" binding:"required"`
+		Participants []string `json:"participants" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Println("Failed to bind JSON:", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sess, ok := sessionFromRequest(c, request.SessionID)
+	if !ok {
+		return
+	}
+
+	participants, err := parseParticipantJIDs(request.Participants)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	info, err := sess.Client.CreateGroup(whatsmeow.ReqCreateGroup{
+		Name:         request.Name,
+		Participants: participants,
+	})
+	if err != nil {
+		log.Println("Failed to create group:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jid": info.JID.String(), "name": info.Name})
+}
+
+// Handler to list every group the session has joined
+func listGroupsHandler(c *gin.Context) {
+	sess, ok := sessionFromRequest(c, "")
+	if !ok {
+		return
+	}
+
+	groups, err := sess.Client.GetJoinedGroups()
+	if err != nil {
+		log.Println("Failed to list groups:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := make([]gin.H, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, gin.H{
+			"jid":          g.JID.String(),
+			"name":         g.Name,
+			"participants": len(g.Participants),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"groups": result})
+}
+
+// Handler to add or remove participants from a group
+func updateGroupParticipantsHandler(c *gin.Context) {
+	var request struct {
+		SessionID    string   `json:"session_id"`
+		Action       string   `json:"action" binding:"required"` // "add" or "remove"
+		Participants []string `json:"participants" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Println("Failed to bind JSON:", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sess, ok := sessionFromRequest(c, request.SessionID)
+	if !ok {
+		return
+	}
+
+	groupJID, err := types.ParseJID(c.Param("jid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid group JID: %v", err)})
+		return
+	}
+
+	var action whatsmeow.ParticipantChangeAction
+	switch request.Action {
+	case "add":
+		action = whatsmeow.ParticipantChangeAdd
+	case "remove":
+		action = whatsmeow.ParticipantChangeRemove
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported action %q", request.Action)})
+		return
+	}
+
+	participants, err := parseParticipantJIDs(request.Participants)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := sess.Client.UpdateGroupParticipants(groupJID, participants, action)
+	if err != nil {
+		log.Println("Failed to update group participants:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
@@ -6,19 +6,17 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
-	"image/png"
+	"io"
 	"log"
+	"mime"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/mdp/qrterminal/v3"
-	"github.com/skip2/go-qrcode"
-	"go.mau.fi/whatsmeow"
 	waProto "go.mau.fi/whatsmeow/binary/proto"
-	"go.mau.fi/whatsmeow/store/sqlstore"
 	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
 	"google.golang.org/protobuf/proto"
@@ -27,194 +25,247 @@ import (
 	logrus "github.com/sirupsen/logrus"
 )
 
-var (
-	client      *whatsmeow.Client
-	csvFilePath = "messages.csv"
-	csvMutex    sync.Mutex
-)
+var sessionManager = NewSessionManager()
+var appConfig = LoadConfig()
 
 func main() {
 	// Setup logging
 	logrus.SetLevel(logrus.DebugLevel)
 
-	// Setup database
-	container, err := sqlstore.New("sqlite3", "file:whatsmeow.db?_foreign_keys=on", nil)
-	if err != nil {
-		log.Fatalf("Failed to create container: %v", err)
+	if _, err := sessionManager.Create("default"); err != nil {
+		log.Fatalf("Failed to create default session: %v", err)
 	}
 
-	deviceStore, err := container.GetFirstDevice()
+	// Set up Gin
+	router := gin.Default()
+	router.POST("/sessions", createSessionHandler)
+	router.GET("/sessions", listSessionsHandler)
+	router.DELETE("/sessions/:id", deleteSessionHandler)
+	router.POST("/send", sendMessageHandler)
+	router.POST("/send/bulk", sendBulkMessagesHandler)
+	router.GET("/qr/stream", qrStreamHandler) // SSE stream of QR and connection events
+	router.GET("/csv", getCSVContentsHandler) // Add endpoint to get CSV contents
+	router.GET("/media/:id", getMediaHandler)
+	router.POST("/media/:id/redownload", redownloadMediaHandler)
+	router.POST("/groups", createGroupHandler)
+	router.GET("/groups", listGroupsHandler)
+	router.POST("/groups/:jid/participants", updateGroupParticipantsHandler)
+	log.Println("Starting server on port 8080")
+	router.Run(":8080")
+}
+
+// Handler to create a new session
+func createSessionHandler(c *gin.Context) {
+	var request struct {
+		ID string `json:"id"`
+	}
+	// The id is optional, so a missing/empty body is fine.
+	_ = c.ShouldBindJSON(&request)
+
+	sess, err := sessionManager.Create(request.ID)
 	if err != nil {
-		log.Fatalf("Failed to get device: %v", err)
+		log.Println("Failed to create session:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	// Create client
-	client = whatsmeow.NewClient(deviceStore, nil)
-	if client.Store.ID == nil {
-		qrChannel, _ := client.GetQRChannel(context.Background())
-		err = client.Connect()
-		if err != nil {
-			log.Fatalf("Failed to connect: %v", err)
-		}
+	c.JSON(http.StatusCreated, gin.H{"id": sess.ID})
+}
 
-		go func() {
-			for evt := range qrChannel {
-				if evt.Event == "code" {
-					qrterminal.GenerateHalfBlock(evt.Code, qrterminal.L, os.Stdout)
-					saveQRCode(evt.Code)      // Save the QR code to be used by the API
-					sendQRCodeToAPI(evt.Code) // Send the QR code to the specified API
-				} else {
-					log.Printf("QR Channel result: %s", evt.Event)
-				}
-			}
-		}()
-	} else {
-		err = client.Connect()
-		if err != nil {
-			log.Fatalf("Failed to connect: %v", err)
-		}
+// Handler to list active sessions
+func listSessionsHandler(c *gin.Context) {
+	sessions := sessionManager.List()
+	ids := make([]gin.H, 0, len(sessions))
+	for _, sess := range sessions {
+		ids = append(ids, gin.H{
+			"id":        sess.ID,
+			"logged_in": sess.Client.IsLoggedIn(),
+			"connected": sess.Client.IsConnected(),
+		})
 	}
+	c.JSON(http.StatusOK, gin.H{"sessions": ids})
+}
 
-	// Handle received messages and other events
-	client.AddEventHandler(func(evt interface{}) {
-		switch v := evt.(type) {
-		case *events.Message:
-			handleReceivedMessage(v)
-		case *events.Connected:
-			fmt.Println("Connected to WhatsApp")
-		case *events.OfflineSyncCompleted:
-			fmt.Println("Offline sync completed")
-		case *events.LoggedOut:
-			fmt.Println("Logged out")
-		case *events.Disconnected:
-			fmt.Println("Disconnected")
-		default:
-			fmt.Printf("Unhandled event: %T\n", v)
-		}
-	})
+// Handler to log a session out and wipe its CSV/media files
+func deleteSessionHandler(c *gin.Context) {
+	id := c.Param("id")
+	if err := sessionManager.Delete(id); err != nil {
+		log.Println("Failed to delete session:", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Session deleted"})
+}
 
-	// Set up Gin
-	router := gin.Default()
-	router.POST("/send", sendMessageHandler)
-	router.GET("/qr/text", generateQRTextHandler)   // Add QR code text endpoint
-	router.GET("/qr/photo", generateQRPhotoHandler) // Add QR code photo endpoint
-	router.GET("/csv", getCSVContentsHandler)       // Add endpoint to get CSV contents
-	log.Println("Starting server on port 8080")
-	router.Run(":8080")
+// sessionFromRequest resolves a session_id (passed explicitly, e.g. from a
+// bound JSON body, or otherwise taken from the query string) to a Session,
+// falling back to "default" so existing single-session callers keep working.
+func sessionFromRequest(c *gin.Context, sessionID string) (*Session, bool) {
+	if sessionID == "" {
+		sessionID = c.Query("session_id")
+	}
+	if sessionID == "" {
+		sessionID = "default"
+	}
+	sess, ok := sessionManager.Get(sessionID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("session %q not found", sessionID)})
+		return nil, false
+	}
+	return sess, true
 }
 
 // Function to handle received messages
-func handleReceivedMessage(message *events.Message) {
+func handleReceivedMessage(sess *Session, message *events.Message) {
+	chatJID := message.Info.Chat.String()
 	sender := message.Info.Sender.String()
 	msg := message.Message
 	timestamp := message.Info.Timestamp.Format(time.RFC3339) // Format the timestamp
 
-	log.Printf("Received message from %s at %s", sender, timestamp)
+	log.Printf("[%s] Received message from %s in %s at %s", sess.ID, sender, chatJID, timestamp)
 
 	if msg.GetConversation() != "" {
 		conversation := msg.GetConversation()
 		log.Printf("Conversation: %s\n", conversation)
-		writeToCSV(sender, "Conversation", conversation, timestamp)
+		recordMessageEvent(sess, chatJID, sender, "Conversation", conversation, timestamp, 1, "received", message)
 
 	} else if extendedText := msg.GetExtendedTextMessage(); extendedText != nil {
 		extendedTextMsg := extendedText.GetText()
 		log.Printf("Extended Text Message: %s\n", extendedTextMsg)
-		writeToCSV(sender, "ExtendedText", extendedTextMsg, timestamp)
+		recordMessageEvent(sess, chatJID, sender, "ExtendedText", extendedTextMsg, timestamp, 1, "received", message)
 
 	} else if imageMessage := msg.GetImageMessage(); imageMessage != nil {
 		caption := imageMessage.GetCaption()
-		imageData, err := client.Download(imageMessage) // Correctly download image data
+		imageData, err := sess.Client.Download(imageMessage) // Correctly download image data
 		if err != nil {
 			log.Printf("Failed to download image data: %v", err)
 			return
 		}
 		log.Println("Received an image message")
-		imagePath, err := saveMedia("image", imageData)
+		imagePath, err := saveMedia(sess, "image", imageMessage.GetMimetype(), imageData)
 		if err != nil {
 			log.Printf("Failed to save image: %v", err)
 			return
 		}
-		writeToCSV(sender, "Image", fmt.Sprintf("Caption: %s, Path: %s", caption, imagePath), timestamp)
+		persistMediaMetadata(sess, message.Info, "image", imageMessage, imageMessage.GetURL(), imageMessage.GetDirectPath(), imageMessage.GetMediaKey(), imageMessage.GetFileEncSHA256(), imageMessage.GetFileSHA256(), imageMessage.GetFileLength(), imageMessage.GetMimetype(), imagePath)
+		recordMessageEvent(sess, chatJID, sender, "Image", fmt.Sprintf("Caption: %s, Path: %s", caption, imagePath), timestamp, 1, "received", message)
 	} else if videoMessage := msg.GetVideoMessage(); videoMessage != nil {
 		caption := videoMessage.GetCaption()
-		videoData, err := client.Download(videoMessage) // Correctly download video data
+		videoData, err := sess.Client.Download(videoMessage) // Correctly download video data
 		if err != nil {
 			log.Printf("Failed to download video data: %v", err)
 			return
 		}
 		log.Println("Received a video message")
-		videoPath, err := saveMedia("video", videoData)
+		videoPath, err := saveMedia(sess, "video", videoMessage.GetMimetype(), videoData)
 		if err != nil {
 			log.Printf("Failed to save video: %v", err)
 			return
 		}
-		writeToCSV(sender, "Video", fmt.Sprintf("Caption: %s, Path: %s", caption, videoPath), timestamp)
+		persistMediaMetadata(sess, message.Info, "video", videoMessage, videoMessage.GetURL(), videoMessage.GetDirectPath(), videoMessage.GetMediaKey(), videoMessage.GetFileEncSHA256(), videoMessage.GetFileSHA256(), videoMessage.GetFileLength(), videoMessage.GetMimetype(), videoPath)
+		recordMessageEvent(sess, chatJID, sender, "Video", fmt.Sprintf("Caption: %s, Path: %s", caption, videoPath), timestamp, 1, "received", message)
 
 	} else if documentMessage := msg.GetDocumentMessage(); documentMessage != nil {
 		fileName := documentMessage.GetFileName()
-		documentData, err := client.Download(documentMessage) // Correctly download document data
+		documentData, err := sess.Client.Download(documentMessage) // Correctly download document data
 		if err != nil {
 			log.Printf("Failed to download document data: %v", err)
 			return
 		}
 		log.Println("Received a document message")
-		documentPath, err := saveMedia("document", documentData)
+		documentPath, err := saveMedia(sess, "document", documentMessage.GetMimetype(), documentData)
 		if err != nil {
 			log.Printf("Failed to save document: %v", err)
 			return
 		}
-		writeToCSV(sender, "Document", fmt.Sprintf("FileName: %s, Path: %s", fileName, documentPath), timestamp)
+		persistMediaMetadata(sess, message.Info, "document", documentMessage, documentMessage.GetURL(), documentMessage.GetDirectPath(), documentMessage.GetMediaKey(), documentMessage.GetFileEncSHA256(), documentMessage.GetFileSHA256(), documentMessage.GetFileLength(), documentMessage.GetMimetype(), documentPath)
+		recordMessageEvent(sess, chatJID, sender, "Document", fmt.Sprintf("FileName: %s, Path: %s", fileName, documentPath), timestamp, 1, "received", message)
 
 	} else if audioMessage := msg.GetAudioMessage(); audioMessage != nil {
-		audioData, err := client.Download(audioMessage) // Correctly download audio data
+		audioData, err := sess.Client.Download(audioMessage) // Correctly download audio data
 		if err != nil {
 			log.Printf("Failed to download audio data: %v", err)
 			return
 		}
 		log.Println("Received an audio message")
-		audioPath, err := saveMedia("audio", audioData)
+		audioPath, err := saveMedia(sess, "audio", audioMessage.GetMimetype(), audioData)
 		if err != nil {
 			log.Printf("Failed to save audio: %v", err)
 			return
 		}
-		writeToCSV(sender, "Audio", audioPath, timestamp)
+		persistMediaMetadata(sess, message.Info, "audio", audioMessage, audioMessage.GetURL(), audioMessage.GetDirectPath(), audioMessage.GetMediaKey(), audioMessage.GetFileEncSHA256(), audioMessage.GetFileSHA256(), audioMessage.GetFileLength(), audioMessage.GetMimetype(), audioPath)
+		recordMessageEvent(sess, chatJID, sender, "Audio", audioPath, timestamp, 1, "received", message)
 
 	} else if contactMessage := msg.GetContactMessage(); contactMessage != nil {
 		contactName := contactMessage.GetDisplayName()
 		log.Println("Received a contact message")
-		writeToCSV(sender, "Contact", contactName, timestamp)
+		recordMessageEvent(sess, chatJID, sender, "Contact", contactName, timestamp, 1, "received", message)
 
 	} else if locationMessage := msg.GetLocationMessage(); locationMessage != nil {
 		location := fmt.Sprintf("Lat: %f, Long: %f", locationMessage.GetDegreesLatitude(), locationMessage.GetDegreesLongitude())
 		log.Println("Received a location message")
-		writeToCSV(sender, "Location", location, timestamp)
+		recordMessageEvent(sess, chatJID, sender, "Location", location, timestamp, 1, "received", message)
 
 	} else {
 		log.Printf("Received an unhandled message type from %s\n", sender)
-		writeToCSV(sender, "Unknown", "Unknown message type", timestamp)
+		recordMessageEvent(sess, chatJID, sender, "Unknown", "Unknown message type", timestamp, 1, "received", message)
 	}
 }
 
-// Function to send a message
-func sendMessage(client *whatsmeow.Client, jid string, text string) error {
-	targetJID := types.NewJID(jid, "s.whatsapp.net")
-	msgID := client.GenerateMessageID()
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second) // Increased timeout to 60 seconds
-	defer cancel()
+// parseTargetJID accepts either a bare phone number (assumed to be an
+// individual chat) or a fully qualified JID such as a group's
+// "<id>@g.us", so /send and /send/bulk work for both 1:1 and group chats.
+func parseTargetJID(jid string) (types.JID, error) {
+	if strings.Contains(jid, "@") {
+		return types.ParseJID(jid)
+	}
+	return types.NewJID(jid, types.DefaultUserServer), nil
+}
 
-	_, err := client.SendMessage(ctx, targetJID, &waProto.Message{
-		Conversation: proto.String(text),
-	})
+// Function to send a message, retrying transient failures with exponential
+// backoff up to appConfig.RetryMaxAttempts times.
+func sendMessage(sess *Session, jid string, text string) error {
+	targetJID, err := parseTargetJID(jid)
 	if err != nil {
-		log.Printf("Failed to send message: %v", err)
-		return err
+		return fmt.Errorf("invalid JID %q: %w", jid, err)
+	}
+	msgID := sess.Client.GenerateMessageID()
+
+	var lastErr error
+	attempts := 0
+	for attempt := 1; attempt <= appConfig.RetryMaxAttempts; attempt++ {
+		attempts = attempt
+
+		ctx, cancel := context.WithTimeout(context.Background(), appConfig.MessageHandlingDeadline)
+		_, err := sess.Client.SendMessage(ctx, targetJID, &waProto.Message{
+			Conversation: proto.String(text),
+		})
+		cancel()
+
+		lastErr = err
+		if err == nil || !isTransientError(err) || attempt == appConfig.RetryMaxAttempts {
+			break
+		}
+
+		backoff := appConfig.RetryBaseBackoff * time.Duration(1<<(attempt-1))
+		log.Printf("Send to %s failed (attempt %d/%d), retrying in %s: %v", jid, attempt, appConfig.RetryMaxAttempts, backoff, err)
+		time.Sleep(backoff)
+	}
+
+	ownJID := "me"
+	if sess.Client.Store.ID != nil {
+		ownJID = sess.Client.Store.ID.String()
 	}
-	fmt.Println("Message sent, ID:", msgID)
 
-	// Format the current time for CSV logging
 	timestamp := time.Now().Format(time.RFC3339)
-	// Записываем отправленное сообщение в CSV
-	writeToCSV("me", "SentMessage", text, timestamp)
+	if lastErr != nil {
+		log.Printf("Failed to send message to %s after %d attempt(s): %v", jid, attempts, lastErr)
+		recordMessageEvent(sess, targetJID.String(), ownJID, "SentMessage", text, timestamp, attempts, "failed", nil)
+		return lastErr
+	}
+
+	fmt.Println("Message sent, ID:", msgID)
+	recordMessageEvent(sess, targetJID.String(), ownJID, "SentMessage", text, timestamp, attempts, "delivered", nil)
 
 	return nil
 }
@@ -222,8 +273,9 @@ func sendMessage(client *whatsmeow.Client, jid string, text string) error {
 // Handler to send a message
 func sendMessageHandler(c *gin.Context) {
 	var request struct {
-		JID  string `json:"jid" binding:"required"`
-		Text string `json:"text" binding:"required"`
+		SessionID string `json:"session_id"`
+		JID       string `json:"jid" binding:"required"`
+		Text      string `json:"text" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&request); err != nil {
 		log.Println("Failed to bind JSON:", err)
@@ -231,8 +283,13 @@ func sendMessageHandler(c *gin.Context) {
 		return
 	}
 
+	sess, ok := sessionFromRequest(c, request.SessionID)
+	if !ok {
+		return
+	}
+
 	log.Println("Received request to send message:", request)
-	err := sendMessage(client, request.JID, request.Text)
+	err := sendMessage(sess, request.JID, request.Text)
 	if err != nil {
 		log.Println("Failed to send message:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
@@ -243,24 +300,75 @@ func sendMessageHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "Message sent"})
 }
 
-// Function to save QR code to a file
-func saveQRCode(code string) {
-	file, err := os.Create("qrcode.txt")
-	if err != nil {
-		log.Printf("Failed to create QR code file: %v", err)
+type bulkSendRequest struct {
+	JID  string `json:"jid" binding:"required"`
+	Text string `json:"text" binding:"required"`
+}
+
+type bulkSendResult struct {
+	JID    string `json:"jid"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Handler to send a batch of messages through a worker pool, honoring the
+// same deadline/backoff as a single send, rather than serializing every
+// message through one request like /send does.
+func sendBulkMessagesHandler(c *gin.Context) {
+	var request struct {
+		SessionID string            `json:"session_id"`
+		Messages  []bulkSendRequest `json:"messages" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Println("Failed to bind JSON:", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	defer file.Close()
 
-	_, err = file.WriteString(code)
-	if err != nil {
-		log.Printf("Failed to write QR code to file: %v", err)
+	sess, ok := sessionFromRequest(c, request.SessionID)
+	if !ok {
+		return
 	}
+
+	results := make([]bulkSendResult, len(request.Messages))
+	jobs := make(chan int)
+
+	workers := appConfig.BulkWorkerCount
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(request.Messages) {
+		workers = len(request.Messages)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				msg := request.Messages[i]
+				if err := sendMessage(sess, msg.JID, msg.Text); err != nil {
+					results[i] = bulkSendResult{JID: msg.JID, Status: "failed", Error: err.Error()}
+				} else {
+					results[i] = bulkSendResult{JID: msg.JID, Status: "sent"}
+				}
+			}
+		}()
+	}
+
+	for i := range request.Messages {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
 }
 
 // Function to send QR code to the specified API
-func sendQRCodeToAPI(code string) {
-	jsonData := map[string]string{"qr_code": code}
+func sendQRCodeToAPI(sessionID string, code string) {
+	jsonData := map[string]string{"session_id": sessionID, "qr_code": code}
 	jsonValue, err := json.Marshal(jsonData)
 	if err != nil {
 		log.Printf("Failed to marshal JSON: %v", err)
@@ -281,88 +389,64 @@ func sendQRCodeToAPI(code string) {
 	}
 }
 
-// Handler to generate and send QR code as text
-func generateQRTextHandler(c *gin.Context) {
-	code, err := os.ReadFile("qrcode.txt")
-	if err != nil {
-		log.Printf("Failed to read QR code file: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate QR code"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"qr_code": string(code)})
-}
-
-// Handler to generate and send QR code as photo
-func generateQRPhotoHandler(c *gin.Context) {
-	code, err := os.ReadFile("qrcode.txt")
-	if err != nil {
-		log.Printf("Failed to read QR code file: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate QR code"})
+// Handler that upgrades to Server-Sent Events and pushes every QR rotation
+// and connection lifecycle event for a session in real time, replacing the
+// old qrcode.txt polling flow (which could be hit before any code had been
+// written, or serve a stale code after login).
+func qrStreamHandler(c *gin.Context) {
+	sess, ok := sessionFromRequest(c, "")
+	if !ok {
 		return
 	}
 
-	qr, err := qrcode.New(string(code), qrcode.Medium)
-	if err != nil {
-		log.Printf("Failed to generate QR code image: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate QR code"})
-		return
-	}
-
-	var pngBuffer bytes.Buffer
-	err = png.Encode(&pngBuffer, qr.Image(256))
-	if err != nil {
-		log.Printf("Failed to encode QR code image: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate QR code"})
-		return
-	}
+	stream, unsubscribe := sess.Broker.Subscribe()
+	defer unsubscribe()
 
-	c.Header("Content-Type", "image/png")
-	c.Writer.Write(pngBuffer.Bytes())
-}
-
-// Function to write a message to the CSV file
-func writeToCSV(sender string, messageType string, message string, timestamp string) {
-	csvMutex.Lock()
-	defer csvMutex.Unlock()
-
-	// Create file if it doesn't exist and open it in append mode
-	file, err := os.OpenFile(csvFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		log.Printf("Failed to open CSV file: %v", err)
-		return
-	}
-	defer file.Close()
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	// Check if the file is empty to write headers
-	info, err := file.Stat()
-	if err != nil {
-		log.Printf("Failed to get file info: %v", err)
-		return
-	}
-
-	if info.Size() == 0 {
-		// Write headers
-		err = writer.Write([]string{"id", "phone", "type", "text", "datetime"})
-		if err != nil {
-			log.Printf("Failed to write headers to CSV file: %v", err)
-			return
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case evt, ok := <-stream:
+			if !ok {
+				return false
+			}
+			c.SSEvent("message", evt)
+			return true
+		case <-c.Request.Context().Done():
+			return false
 		}
-	}
+	})
+}
 
-	// Write message to CSV
-	err = writer.Write([]string{fmt.Sprintf("%d", time.Now().UnixNano()), sender, messageType, message, timestamp})
-	if err != nil {
-		log.Printf("Failed to write to CSV file: %v", err)
-	}
+// recordMessageEvent builds a MessageEvent for a single inbound or outbound
+// message and hands it to the session's sinks. raw carries the originating
+// *events.Message for inbound events, so sinks that want it (e.g. JSONL) can
+// preserve the full event instead of just the flattened fields; it is nil
+// for outbound sends, which have no corresponding events.Message.
+func recordMessageEvent(sess *Session, chatJID string, senderJID string, messageType string, message string, timestamp string, attempts int, outcome string, raw interface{}) {
+	sess.Sinks.Enqueue(MessageEvent{
+		SessionID: sess.ID,
+		ChatJID:   chatJID,
+		SenderJID: senderJID,
+		Type:      messageType,
+		Text:      message,
+		Timestamp: timestamp,
+		Attempts:  attempts,
+		Outcome:   outcome,
+		Raw:       raw,
+	})
 }
 
 // Handler to get CSV contents
 func getCSVContentsHandler(c *gin.Context) {
-	file, err := os.Open(csvFilePath)
+	sess, ok := sessionFromRequest(c, "")
+	if !ok {
+		return
+	}
+
+	file, err := os.Open(sess.CSVPath)
 	if err != nil {
 		log.Printf("Failed to open CSV file: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open CSV file"})
@@ -381,26 +465,17 @@ func getCSVContentsHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": records})
 }
 
-// Function to save media files
-func saveMedia(mediaType string, mediaData []byte) (string, error) {
-	// Define the directory and filename based on the media type and current timestamp
-	dir := fmt.Sprintf("media/%s", mediaType)
+// Function to save media files under a session's own media directory
+func saveMedia(sess *Session, mediaType string, mimetype string, mediaData []byte) (string, error) {
+	dir := fmt.Sprintf("%s/%s", sess.MediaDir, mediaType)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		log.Printf("Failed to create directory: %v", err)
 		return "", err
 	}
-	var extension string
-	switch mediaType {
-	case "image":
-		extension = ".jpg" // or ".png", depending on your use case
-	case "video":
-		extension = ".mp4"
-	case "audio":
-		extension = ".ogg" // or ".mp3", depending on your use case
-	case "document":
-		extension = ".pdf" // or any other relevant extension
-	default:
-		extension = ""
+
+	extension := defaultMediaExtension(mediaType)
+	if exts, err := mime.ExtensionsByType(mimetype); err == nil && len(exts) > 0 {
+		extension = exts[0]
 	}
 
 	filename := fmt.Sprintf("%s/%d%s", dir, time.Now().UnixNano(), extension)
@@ -415,3 +490,20 @@ func saveMedia(mediaType string, mediaData []byte) (string, error) {
 	log.Printf("Saved media file: %s", filename) // Log the path of the saved file
 	return filename, nil
 }
+
+// defaultMediaExtension is the fallback used when mime.ExtensionsByType
+// doesn't recognize the message's reported mimetype.
+func defaultMediaExtension(mediaType string) string {
+	switch mediaType {
+	case "image":
+		return ".jpg"
+	case "video":
+		return ".mp4"
+	case "audio":
+		return ".ogg"
+	case "document":
+		return ".pdf"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// persistMediaMetadata records everything needed to re-download a piece of
+// media later: the WhatsApp-issued keys and the original proto message
+// itself (so client.Download can be replayed against it).
+func persistMediaMetadata(sess *Session, info types.MessageInfo, mediaType string, raw proto.Message, url, directPath string, mediaKey, fileEncSHA256, fileSHA256 []byte, fileLength uint64, mimetype, localPath string) {
+	rawBytes, err := proto.Marshal(raw)
+	if err != nil {
+		log.Printf("Failed to marshal %s message for media storage: %v", mediaType, err)
+		return
+	}
+
+	rec := MediaRecord{
+		MessageID:     info.ID,
+		SessionID:     sess.ID,
+		ChatJID:       info.Chat.String(),
+		SenderJID:     info.Sender.String(),
+		MediaType:     mediaType,
+		URL:           url,
+		DirectPath:    directPath,
+		MediaKey:      mediaKey,
+		FileEncSHA256: fileEncSHA256,
+		FileSHA256:    fileSHA256,
+		FileLength:    fileLength,
+		Mimetype:      mimetype,
+		RawProto:      rawBytes,
+		LocalPath:     localPath,
+	}
+
+	if err := saveMediaRecord(sess.MediaDB, rec); err != nil {
+		log.Printf("Failed to persist media metadata for message %q: %v", info.ID, err)
+	}
+}
+
+// Handler to look up a previously received media message's metadata.
+func getMediaHandler(c *gin.Context) {
+	sess, ok := sessionFromRequest(c, "")
+	if !ok {
+		return
+	}
+
+	messageID := c.Param("id")
+	rec, err := getMediaRecord(sess.MediaDB, messageID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("media for message %q not found", messageID)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message_id": rec.MessageID,
+		"chat_jid":   rec.ChatJID,
+		"sender_jid": rec.SenderJID,
+		"media_type": rec.MediaType,
+		"mimetype":   rec.Mimetype,
+		"local_path": rec.LocalPath,
+	})
+}
+
+// Handler to re-download a previously received media message, even if the
+// file on disk has since been deleted.
+func redownloadMediaHandler(c *gin.Context) {
+	sess, ok := sessionFromRequest(c, "")
+	if !ok {
+		return
+	}
+
+	messageID := c.Param("id")
+	rec, err := getMediaRecord(sess.MediaDB, messageID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("media for message %q not found", messageID)})
+		return
+	}
+
+	data, err := redownloadMedia(sess, rec)
+	if err != nil {
+		log.Printf("Failed to redownload media for message %q: %v", messageID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	path, err := saveMedia(sess, rec.MediaType, rec.Mimetype, data)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save redownloaded media"})
+		return
+	}
+
+	if err := updateMediaLocalPath(sess.MediaDB, messageID, path); err != nil {
+		log.Printf("Failed to update media record for message %q: %v", messageID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"path": path})
+}
+
+// whatsmeowMediaType maps our string MediaType to whatsmeow's own enum, for
+// calls into DownloadMediaWithPath.
+func whatsmeowMediaType(mediaType string) whatsmeow.MediaType {
+	switch mediaType {
+	case "image":
+		return whatsmeow.MediaImage
+	case "video":
+		return whatsmeow.MediaVideo
+	case "audio":
+		return whatsmeow.MediaAudio
+	case "document":
+		return whatsmeow.MediaDocument
+	default:
+		return whatsmeow.MediaImage
+	}
+}
+
+// redownloadMedia tries three routes, from most to least likely to work:
+//  1. Replay the persisted proto through client.Download, the normal
+//     whatsmeow path, which works as long as whatsmeow's own session still
+//     has the media cached.
+//  2. Ask whatsmeow to re-resolve a fresh media host and rebuild the
+//     download URL from the persisted DirectPath. This is what actually
+//     makes redownload durable across time: the original URL expires, but
+//     DirectPath plus a freshly resolved host does not.
+//  3. Fetch the originally persisted URL directly and decrypt it by hand.
+//     This only helps within the URL's own expiry window, so it's a last
+//     resort rather than the primary recovery path.
+func redownloadMedia(sess *Session, rec *MediaRecord) ([]byte, error) {
+	var data []byte
+	var downloadErr error
+
+	switch rec.MediaType {
+	case "image":
+		msg := &waProto.ImageMessage{}
+		if proto.Unmarshal(rec.RawProto, msg) == nil {
+			data, downloadErr = sess.Client.Download(msg)
+		}
+	case "video":
+		msg := &waProto.VideoMessage{}
+		if proto.Unmarshal(rec.RawProto, msg) == nil {
+			data, downloadErr = sess.Client.Download(msg)
+		}
+	case "audio":
+		msg := &waProto.AudioMessage{}
+		if proto.Unmarshal(rec.RawProto, msg) == nil {
+			data, downloadErr = sess.Client.Download(msg)
+		}
+	case "document":
+		msg := &waProto.DocumentMessage{}
+		if proto.Unmarshal(rec.RawProto, msg) == nil {
+			data, downloadErr = sess.Client.Download(msg)
+		}
+	}
+
+	if downloadErr == nil && data != nil {
+		return data, nil
+	}
+
+	log.Printf("client.Download unavailable for message %q (%v), re-resolving media host from DirectPath", rec.MessageID, downloadErr)
+	data, downloadErr = sess.Client.DownloadMediaWithPath(rec.DirectPath, rec.FileEncSHA256, rec.FileSHA256, rec.MediaKey, int(rec.FileLength), whatsmeowMediaType(rec.MediaType), "")
+	if downloadErr == nil && data != nil {
+		return data, nil
+	}
+
+	log.Printf("DownloadMediaWithPath failed for message %q (%v), falling back to the persisted URL", rec.MessageID, downloadErr)
+	return downloadAndDecryptMedia(rec)
+}
+
+func downloadAndDecryptMedia(rec *MediaRecord) ([]byte, error) {
+	resp, err := http.Get(rec.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch encrypted media: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status fetching media: %s", resp.Status)
+	}
+
+	encrypted, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encrypted media: %w", err)
+	}
+
+	return decryptMediaPayload(rec.MediaType, rec.MediaKey, encrypted)
+}
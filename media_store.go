@@ -0,0 +1,102 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// MediaRecord is the metadata whatsmeow hands back for every incoming
+// image/video/audio/document message, persisted so media can be
+// re-downloaded later even after the in-memory message is gone.
+type MediaRecord struct {
+	MessageID     string
+	SessionID     string
+	ChatJID       string
+	SenderJID     string
+	MediaType     string
+	URL           string
+	DirectPath    string
+	MediaKey      []byte
+	FileEncSHA256 []byte
+	FileSHA256    []byte
+	FileLength    uint64
+	Mimetype      string
+	RawProto      []byte
+	LocalPath     string
+}
+
+// openMediaDB opens (creating if necessary) the SQLite database a session
+// uses to remember media metadata, separate from whatsmeow's own device
+// store.
+func openMediaDB(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on", path))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS media (
+			message_id TEXT PRIMARY KEY,
+			session_id TEXT NOT NULL,
+			chat_jid TEXT,
+			sender_jid TEXT,
+			media_type TEXT NOT NULL,
+			url TEXT,
+			direct_path TEXT,
+			media_key BLOB,
+			file_enc_sha256 BLOB,
+			file_sha256 BLOB,
+			file_length INTEGER,
+			mimetype TEXT,
+			raw_proto BLOB,
+			local_path TEXT
+		)
+	`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func saveMediaRecord(db *sql.DB, rec MediaRecord) error {
+	_, err := db.Exec(`
+		INSERT OR REPLACE INTO media (
+			message_id, session_id, chat_jid, sender_jid, media_type,
+			url, direct_path, media_key, file_enc_sha256, file_sha256,
+			file_length, mimetype, raw_proto, local_path
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		rec.MessageID, rec.SessionID, rec.ChatJID, rec.SenderJID, rec.MediaType,
+		rec.URL, rec.DirectPath, rec.MediaKey, rec.FileEncSHA256, rec.FileSHA256,
+		rec.FileLength, rec.Mimetype, rec.RawProto, rec.LocalPath,
+	)
+	return err
+}
+
+func getMediaRecord(db *sql.DB, messageID string) (*MediaRecord, error) {
+	row := db.QueryRow(`
+		SELECT message_id, session_id, chat_jid, sender_jid, media_type,
+		       url, direct_path, media_key, file_enc_sha256, file_sha256,
+		       file_length, mimetype, raw_proto, local_path
+		FROM media WHERE message_id = ?
+	`, messageID)
+
+	var rec MediaRecord
+	err := row.Scan(
+		&rec.MessageID, &rec.SessionID, &rec.ChatJID, &rec.SenderJID, &rec.MediaType,
+		&rec.URL, &rec.DirectPath, &rec.MediaKey, &rec.FileEncSHA256, &rec.FileSHA256,
+		&rec.FileLength, &rec.Mimetype, &rec.RawProto, &rec.LocalPath,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rec, nil
+}
+
+func updateMediaLocalPath(db *sql.DB, messageID string, localPath string) error {
+	_, err := db.Exec(`UPDATE media SET local_path = ? WHERE message_id = ?`, localPath, messageID)
+	return err
+}
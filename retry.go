@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// isTransientError reports whether err is the kind of failure that's
+// likely to succeed on retry: a deadline that just needed more time, or
+// whatsmeow still reconnecting/the server briefly erroring out. Anything
+// else (bad JID, auth failure, etc.) is treated as permanent.
+func isTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "reconnect"):
+		return true
+	case strings.Contains(msg, "not connected"):
+		return true
+	case strings.Contains(msg, "server error"):
+		return true
+	case strings.Contains(msg, "timeout"):
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store/sqlstore"
+	"go.mau.fi/whatsmeow/types/events"
+
+	logrus "github.com/sirupsen/logrus"
+)
+
+// Session owns every resource tied to a single WhatsApp device: its
+// whatsmeow client, its own sqlstore container, and the CSV/media paths
+// that the rest of the handlers read and write.
+type Session struct {
+	ID        string
+	Client    *whatsmeow.Client
+	Container *sqlstore.Container
+	CSVPath   string
+	MediaDir  string
+	MediaDB   *sql.DB
+	Sinks     *sinkDispatcher
+	Broker    *eventBroker
+}
+
+// SessionManager keeps track of every active Session, keyed by session ID.
+type SessionManager struct {
+	mu       sync.RWMutex
+	sessions map[string]*Session
+}
+
+func NewSessionManager() *SessionManager {
+	return &SessionManager{sessions: make(map[string]*Session)}
+}
+
+// tombstone marks a session id whose Create was cancelled by a Delete that
+// ran while it was still in flight. It is a distinct sentinel from the nil
+// placeholder Create reserves an id with, so Create's final insert can tell
+// "still being created" apart from "deleted out from under me".
+var tombstone = &Session{}
+
+// Get returns the session for id, if one exists. A session whose Create
+// call is still in flight is reserved in the map as a nil placeholder (see
+// Create) and is reported as not-found until that call finishes, as is a
+// tombstoned id.
+func (m *SessionManager) Get(id string) (*Session, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.sessions[id]
+	return s, ok && s != nil && s != tombstone
+}
+
+// List returns every currently managed session, excluding any whose Create
+// call is still in flight or which were tombstoned.
+func (m *SessionManager) List() []*Session {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]*Session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		if s != nil && s != tombstone {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Create sets up a brand new session with its own device store, connects
+// it, and starts streaming its QR channel (if the device isn't already
+// paired). If id is empty, one is generated.
+//
+// The id is reserved in the session map up front and held for the entire
+// setup, not just the existence check: without that, two concurrent
+// Creates for the same id can both pass the check, both open a sqlstore
+// against the same database file, and race to insert into the map while
+// the loser's client/goroutines leak untracked.
+//
+// A Delete racing against this id while setup is still running replaces
+// the reservation with a tombstone instead of removing it outright, so the
+// final insert below can tell it was cancelled and tear the freshly
+// connected session back down instead of resurrecting it in the map.
+func (m *SessionManager) Create(id string) (*Session, error) {
+	if id == "" {
+		id = fmt.Sprintf("session-%d", time.Now().UnixNano())
+	}
+
+	m.mu.Lock()
+	if _, exists := m.sessions[id]; exists {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("session %q already exists", id)
+	}
+	m.sessions[id] = nil
+	m.mu.Unlock()
+
+	sess, err := m.create(id)
+	if err != nil {
+		m.mu.Lock()
+		delete(m.sessions, id)
+		m.mu.Unlock()
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if current := m.sessions[id]; current != nil {
+		// current is the tombstone Delete leaves behind for an id that was
+		// still reserved (nil) when it ran; a real Session can never show
+		// up here since nothing but this Create call writes a non-nil,
+		// non-tombstone value for an id while it still holds the placeholder.
+		m.mu.Unlock()
+		if err := m.teardown(id, sess); err != nil {
+			logrus.Warnf("Failed to tear down session %q cancelled mid-create: %v", id, err)
+		}
+		return nil, fmt.Errorf("session %q was deleted while being created", id)
+	}
+	m.sessions[id] = sess
+	m.mu.Unlock()
+
+	return sess, nil
+}
+
+// create does the actual (unlocked) session setup for id, which Create has
+// already reserved in the session map.
+func (m *SessionManager) create(id string) (*Session, error) {
+	if err := os.MkdirAll("sessions", 0755); err != nil {
+		return nil, fmt.Errorf("failed to create sessions directory: %w", err)
+	}
+
+	dbPath := filepath.Join("sessions", id+".db")
+	container, err := sqlstore.New("sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on", dbPath), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create container for session %q: %w", id, err)
+	}
+
+	deviceStore, err := container.GetFirstDevice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device for session %q: %w", id, err)
+	}
+
+	mediaDir := filepath.Join("media", id)
+	if err := os.MkdirAll(mediaDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create media directory for session %q: %w", id, err)
+	}
+
+	mediaDB, err := openMediaDB(filepath.Join("sessions", id+"-media.db"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open media store for session %q: %w", id, err)
+	}
+
+	csvPath := filepath.Join("messages", id+".csv")
+	sinks, err := buildSinks(id, csvPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up message sinks for session %q: %w", id, err)
+	}
+
+	sess := &Session{
+		ID:        id,
+		Client:    whatsmeow.NewClient(deviceStore, nil),
+		Container: container,
+		CSVPath:   csvPath,
+		MediaDir:  mediaDir,
+		MediaDB:   mediaDB,
+		Sinks:     newSinkDispatcher(sinks, appConfig.SinkQueueSize),
+		Broker:    newEventBroker(),
+	}
+
+	sess.Client.AddEventHandler(func(evt interface{}) {
+		switch v := evt.(type) {
+		case *events.Message:
+			handleReceivedMessage(sess, v)
+		case *events.Connected:
+			logrus.Infof("[%s] Connected to WhatsApp", id)
+			sess.Broker.Publish(StreamEvent{Type: "connected", Timestamp: time.Now()})
+		case *events.OfflineSyncCompleted:
+			logrus.Infof("[%s] Offline sync completed", id)
+			sess.Broker.Publish(StreamEvent{Type: "offline_sync_completed", Timestamp: time.Now()})
+		case *events.LoggedOut:
+			logrus.Infof("[%s] Logged out", id)
+			sess.Broker.Publish(StreamEvent{Type: "logged_out", Timestamp: time.Now()})
+		case *events.Disconnected:
+			logrus.Infof("[%s] Disconnected", id)
+			sess.Broker.Publish(StreamEvent{Type: "disconnected", Timestamp: time.Now()})
+		default:
+			logrus.Debugf("[%s] Unhandled event: %T", id, v)
+		}
+	})
+
+	if sess.Client.Store.ID == nil {
+		qrChannel, _ := sess.Client.GetQRChannel(context.Background())
+		if err := sess.Client.Connect(); err != nil {
+			return nil, fmt.Errorf("failed to connect session %q: %w", id, err)
+		}
+		go streamQRCode(sess, qrChannel)
+	} else {
+		if err := sess.Client.Connect(); err != nil {
+			return nil, fmt.Errorf("failed to connect session %q: %w", id, err)
+		}
+	}
+
+	return sess, nil
+}
+
+// Delete logs the session out, disconnects it, and wipes every file it
+// owns: its media directory/store and whatever its configured sinks
+// persisted. The underlying device store is left in place so a future
+// Create with the same id can still inspect it if needed.
+//
+// If id's Create call is still in flight (its placeholder is still nil),
+// Delete can't tear anything down yet — there's no Session to tear down.
+// It leaves a tombstone in its place instead of deleting the key outright,
+// so Create's final insert sees it was cancelled and tears the session
+// down itself once setup finishes, rather than reinserting a live client
+// that a caller was just told had been deleted.
+func (m *SessionManager) Delete(id string) error {
+	m.mu.Lock()
+	sess, ok := m.sessions[id]
+	if !ok || sess == tombstone {
+		m.mu.Unlock()
+		return fmt.Errorf("session %q not found", id)
+	}
+	if sess == nil {
+		m.sessions[id] = tombstone
+		m.mu.Unlock()
+		return nil
+	}
+	delete(m.sessions, id)
+	m.mu.Unlock()
+
+	return m.teardown(id, sess)
+}
+
+// teardown logs a session out, disconnects it, and removes every file and
+// handle it owns. It's shared by Delete and by Create's cancelled-in-flight
+// path, so a session that never made it into the map is cleaned up exactly
+// the same way as one that did.
+func (m *SessionManager) teardown(id string, sess *Session) error {
+	if sess.Client.IsLoggedIn() {
+		sess.Client.Logout()
+	}
+	sess.Client.Disconnect()
+	sess.Sinks.Close()
+	sess.Sinks.Wipe()
+
+	if err := os.RemoveAll(sess.MediaDir); err != nil {
+		return fmt.Errorf("failed to remove media directory for session %q: %w", id, err)
+	}
+
+	mediaDBPath := filepath.Join("sessions", id+"-media.db")
+	sess.MediaDB.Close()
+	if err := os.Remove(mediaDBPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove media store for session %q: %w", id, err)
+	}
+
+	if err := sess.Container.Close(); err != nil {
+		return fmt.Errorf("failed to close device store for session %q: %w", id, err)
+	}
+
+	return nil
+}
+
+// streamQRCode forwards every item whatsmeow produces on a session's QR
+// channel to its event broker, so SSE subscribers see the code rotate in
+// real time instead of polling a file that may be stale or not exist yet.
+func streamQRCode(sess *Session, qrChannel <-chan whatsmeow.QRChannelItem) {
+	for evt := range qrChannel {
+		sess.Broker.Publish(StreamEvent{Type: evt.Event, Code: evt.Code, Timestamp: time.Now()})
+		if evt.Event == "code" {
+			sendQRCodeToAPI(sess.ID, evt.Code)
+		} else {
+			logrus.Infof("[%s] QR channel result: %s", sess.ID, evt.Event)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+)
+
+// MessageEvent is the canonical record a session hands to its sinks: every
+// inbound message (of any type) and every outbound send attempt goes
+// through this shape before being fanned out.
+type MessageEvent struct {
+	SessionID string      `json:"session_id"`
+	ChatJID   string      `json:"chat_jid"`
+	SenderJID string      `json:"sender_jid"`
+	Type      string      `json:"type"`
+	Text      string      `json:"text"`
+	Timestamp string      `json:"timestamp"`
+	Attempts  int         `json:"attempts"`
+	Outcome   string      `json:"outcome"`
+	Raw       interface{} `json:"raw,omitempty"`
+}
+
+// MessageSink is implemented by every backend a session can record its
+// message events to: CSV, JSONL, SQLite, an HTTP webhook, etc.
+type MessageSink interface {
+	Name() string
+	Write(evt MessageEvent) error
+	Close() error
+	// Wipe removes whatever this sink has persisted for its session (a file,
+	// a database, ...). It is called when the owning session is deleted.
+	Wipe() error
+}
+
+// sinkWorker owns one sink's own queue and goroutine, so a slow or down
+// backend (e.g. a webhook mid-retry-backoff) only backs up its own queue
+// and only drops its own events, instead of stalling or starving every
+// other configured sink.
+type sinkWorker struct {
+	sink  MessageSink
+	queue chan MessageEvent
+}
+
+// sinkDispatcher fans events out to every configured sink concurrently, one
+// goroutine per sink, so a slow sink (disk or network I/O) never blocks the
+// whatsmeow event handler that produced the event or any other sink.
+type sinkDispatcher struct {
+	workers []*sinkWorker
+	wg      sync.WaitGroup
+}
+
+func newSinkDispatcher(sinks []MessageSink, queueSize int) *sinkDispatcher {
+	d := &sinkDispatcher{workers: make([]*sinkWorker, 0, len(sinks))}
+	for _, sink := range sinks {
+		w := &sinkWorker{sink: sink, queue: make(chan MessageEvent, queueSize)}
+		d.workers = append(d.workers, w)
+		d.wg.Add(1)
+		go d.run(w)
+	}
+	return d
+}
+
+func (d *sinkDispatcher) run(w *sinkWorker) {
+	defer d.wg.Done()
+	for evt := range w.queue {
+		if err := w.sink.Write(evt); err != nil {
+			log.Printf("Sink %q write failed: %v", w.sink.Name(), err)
+		}
+	}
+}
+
+// Enqueue never blocks the caller: each sink has its own queue, and if a
+// given sink's queue is full (because that sink specifically is slow or
+// down), only that sink's event is dropped rather than stalling the
+// whatsmeow event loop or the other configured sinks.
+func (d *sinkDispatcher) Enqueue(evt MessageEvent) {
+	for _, w := range d.workers {
+		select {
+		case w.queue <- evt:
+		default:
+			log.Printf("Sink %q queue full, dropping event for %s in %s", w.sink.Name(), evt.Type, evt.ChatJID)
+		}
+	}
+}
+
+func (d *sinkDispatcher) Close() {
+	for _, w := range d.workers {
+		close(w.queue)
+	}
+	d.wg.Wait()
+	for _, w := range d.workers {
+		if err := w.sink.Close(); err != nil {
+			log.Printf("Failed to close sink %q: %v", w.sink.Name(), err)
+		}
+	}
+}
+
+// Wipe removes every configured sink's persisted data. Used when the owning
+// session is deleted so no sink's file/database outlives it.
+func (d *sinkDispatcher) Wipe() {
+	for _, w := range d.workers {
+		if err := w.sink.Wipe(); err != nil {
+			log.Printf("Failed to wipe sink %q: %v", w.sink.Name(), err)
+		}
+	}
+}
+
+// buildSinks instantiates the MessageSink backends listed in
+// appConfig.Sinks for a given session.
+func buildSinks(sessionID string, csvPath string) ([]MessageSink, error) {
+	sinks := make([]MessageSink, 0, len(appConfig.Sinks))
+
+	for _, kind := range appConfig.Sinks {
+		switch kind {
+		case "csv":
+			sinks = append(sinks, newCSVSink(csvPath))
+		case "jsonl":
+			sinks = append(sinks, newJSONLSink(filepath.Join("messages", sessionID+".jsonl")))
+		case "sqlite":
+			sink, err := newSQLiteSink(filepath.Join("sessions", sessionID+"-messages.db"))
+			if err != nil {
+				return nil, fmt.Errorf("failed to open sqlite sink: %w", err)
+			}
+			sinks = append(sinks, sink)
+		case "webhook":
+			if appConfig.WebhookURL == "" {
+				return nil, fmt.Errorf("webhook sink configured without a webhook_url")
+			}
+			sinks = append(sinks, newWebhookSink(appConfig.WebhookURL))
+		default:
+			return nil, fmt.Errorf("unknown sink kind %q", kind)
+		}
+	}
+
+	return sinks, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// csvSink is the original writeToCSV behavior, now behind the MessageSink
+// interface: one row per event, in the same five-plus-attempts/outcome
+// column layout the rest of the tooling already expects.
+type csvSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+func newCSVSink(path string) *csvSink {
+	return &csvSink{path: path}
+}
+
+func (s *csvSink) Name() string {
+	return "csv"
+}
+
+func (s *csvSink) Write(evt MessageEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create messages directory: %w", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open CSV file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to get CSV file info: %w", err)
+	}
+	if info.Size() == 0 {
+		if err := writer.Write([]string{"id", "chat_jid", "sender_jid", "type", "text", "datetime", "attempts", "outcome"}); err != nil {
+			return fmt.Errorf("failed to write CSV headers: %w", err)
+		}
+	}
+
+	return writer.Write([]string{
+		fmt.Sprintf("%d", time.Now().UnixNano()),
+		evt.ChatJID, evt.SenderJID, evt.Type, evt.Text, evt.Timestamp,
+		strconv.Itoa(evt.Attempts), evt.Outcome,
+	})
+}
+
+func (s *csvSink) Close() error {
+	return nil
+}
+
+func (s *csvSink) Wipe() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove CSV file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// jsonlSink writes one JSON object per line, preserving the full event
+// (including the raw events.Message for inbound messages) instead of
+// collapsing it into fixed CSV columns.
+type jsonlSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+func newJSONLSink(path string) *jsonlSink {
+	return &jsonlSink{path: path}
+}
+
+func (s *jsonlSink) Name() string {
+	return "jsonl"
+}
+
+func (s *jsonlSink) Write(evt MessageEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create messages directory: %w", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open JSONL file: %w", err)
+	}
+	defer file.Close()
+
+	line, err := json.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+func (s *jsonlSink) Close() error {
+	return nil
+}
+
+func (s *jsonlSink) Wipe() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove JSONL file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+)
+
+// sqliteSink stores events in a SQLite table with indexes on the columns
+// callers are most likely to filter/query by.
+type sqliteSink struct {
+	path string
+	db   *sql.DB
+}
+
+func newSQLiteSink(path string) (*sqliteSink, error) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on", path))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS messages (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			session_id TEXT NOT NULL,
+			chat_jid TEXT NOT NULL,
+			sender_jid TEXT NOT NULL,
+			type TEXT NOT NULL,
+			text TEXT,
+			datetime TEXT NOT NULL,
+			attempts INTEGER,
+			outcome TEXT
+		)
+	`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	for _, stmt := range []string{
+		`CREATE INDEX IF NOT EXISTS idx_messages_chat_jid ON messages(chat_jid)`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_sender_jid ON messages(sender_jid)`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_datetime ON messages(datetime)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return &sqliteSink{path: path, db: db}, nil
+}
+
+func (s *sqliteSink) Name() string {
+	return "sqlite"
+}
+
+func (s *sqliteSink) Write(evt MessageEvent) error {
+	_, err := s.db.Exec(
+		`INSERT INTO messages (session_id, chat_jid, sender_jid, type, text, datetime, attempts, outcome)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		evt.SessionID, evt.ChatJID, evt.SenderJID, evt.Type, evt.Text, evt.Timestamp, evt.Attempts, evt.Outcome,
+	)
+	return err
+}
+
+func (s *sqliteSink) Close() error {
+	return s.db.Close()
+}
+
+func (s *sqliteSink) Wipe() error {
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove sqlite sink database: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// webhookSink POSTs each event to a configured URL, retrying transient
+// failures with the same backoff policy outbound sends use.
+type webhookSink struct {
+	url string
+}
+
+func newWebhookSink(url string) *webhookSink {
+	return &webhookSink{url: url}
+}
+
+func (s *webhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *webhookSink) Write(evt MessageEvent) error {
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event for webhook: %w", err)
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= appConfig.RetryMaxAttempts; attempt++ {
+		resp, err := http.Post(s.url, "application/json", bytes.NewReader(body))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 500 {
+				if resp.StatusCode >= 300 {
+					return fmt.Errorf("webhook returned %s", resp.Status)
+				}
+				return nil
+			}
+			err = fmt.Errorf("webhook returned %s", resp.Status)
+		}
+
+		lastErr = err
+		if attempt == appConfig.RetryMaxAttempts {
+			break
+		}
+		backoff := appConfig.RetryBaseBackoff * time.Duration(1<<(attempt-1))
+		log.Printf("Webhook delivery failed (attempt %d/%d), retrying in %s: %v", attempt, appConfig.RetryMaxAttempts, backoff, err)
+		time.Sleep(backoff)
+	}
+
+	return fmt.Errorf("webhook delivery failed after %d attempt(s): %w", appConfig.RetryMaxAttempts, lastErr)
+}
+
+func (s *webhookSink) Close() error {
+	return nil
+}
+
+// Wipe is a no-op: a webhook sink has no local state tied to the session to
+// remove, it only ever sends events onward.
+func (s *webhookSink) Wipe() error {
+	return nil
+}